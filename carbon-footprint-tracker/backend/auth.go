@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// sessionTTL is how long an issued token stays valid if it's never
+// explicitly revoked.
+const sessionTTL = 30 * 24 * time.Hour
+
+// Session backs bearer tokens. Only the SHA-256 hash of the token is
+// stored, so a leaked database dump doesn't hand out working tokens.
+type Session struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RegisterDTO struct {
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Location string `json:"location"`
+}
+
+type LoginDTO struct {
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type authResponse struct {
+	Token string `json:"token"`
+	User  User   `json:"user"`
+}
+
+func generateToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *App) issueSession(userID uint) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	session := Session{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(sessionTTL),
+	}
+	if err := a.DB.Create(&session).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (a *App) register(c *gin.Context) {
+	var dto RegisterDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(dto.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	location := dto.Location
+	if location == "" {
+		location = "global"
+	}
+
+	user := User{
+		Name:         dto.Name,
+		PasswordHash: string(hash),
+		Location:     location,
+		Role:         "user",
+	}
+	if err := a.DB.Create(&user).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "name already taken"})
+		return
+	}
+
+	token, err := a.issueSession(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, authResponse{Token: token, User: user})
+}
+
+func (a *App) login(c *gin.Context) {
+	var dto LoginDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+	if err := a.DB.Where("name = ?", dto.Name).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(dto.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
+	}
+
+	token, err := a.issueSession(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, authResponse{Token: token, User: user})
+}
+
+// deleteSession revokes a session (logout from one device, or killing a
+// stolen token). A user can only revoke their own sessions; admins can
+// revoke anyone's.
+func (a *App) deleteSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var session Session
+	if err := a.DB.First(&session, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	callerID := currentUserID(c)
+	if session.UserID != callerID && currentUserRole(c) != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot revoke another user's session"})
+		return
+	}
+
+	now := time.Now()
+	if err := a.DB.Model(&session).Update("revoked_at", &now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": id})
+}
+
+// --------------------
+// Middleware
+// --------------------
+
+var errNoToken = errors.New("missing or malformed Authorization header")
+
+func bearerToken(c *gin.Context) (string, error) {
+	header := c.GetHeader("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return "", errNoToken
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, prefix))
+	if token == "" {
+		return "", errNoToken
+	}
+	return token, nil
+}
+
+// AuthMiddleware resolves "Authorization: Bearer <token>" into a user and
+// stashes userID/userRole on the gin context for handlers to read.
+func AuthMiddleware(a *App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := bearerToken(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		var session Session
+		err = a.DB.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).First(&session).Error
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			return
+		}
+
+		var user User
+		if err := a.DB.First(&user, session.UserID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			return
+		}
+
+		c.Set("userID", user.ID)
+		c.Set("userRole", user.Role)
+		c.Next()
+	}
+}
+
+// RequireAdmin must run after AuthMiddleware.
+func RequireAdmin(c *gin.Context) {
+	if currentUserRole(c) != "admin" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return
+	}
+	c.Next()
+}
+
+func currentUserID(c *gin.Context) uint {
+	v, _ := c.Get("userID")
+	id, _ := v.(uint)
+	return id
+}
+
+func currentUserRole(c *gin.Context) string {
+	v, _ := c.Get("userRole")
+	role, _ := v.(string)
+	return role
+}
+
+// assignOrphanActivitiesToUser is run once at startup so the pre-auth
+// rows created before this migration (owned by nobody) become the demo
+// user's data instead of being invisible to everyone.
+func assignOrphanActivitiesToUser(db *gorm.DB, userID uint) error {
+	return db.Model(&Activity{}).Where("user_id = 0 OR user_id IS NULL").Update("user_id", userID).Error
+}
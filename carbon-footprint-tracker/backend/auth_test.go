@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupAuthTestApp wires the same auth routes main() does, against an
+// in-memory DB, so middleware/handlers are exercised the way they're
+// actually mounted rather than called directly.
+func setupAuthTestApp(t *testing.T) (*App, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}, &Activity{}, &Session{}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &App{DB: db}
+	r := gin.New()
+	r.POST("/api/auth/register", app.register)
+	r.POST("/api/auth/login", app.login)
+
+	authorized := r.Group("/api")
+	authorized.Use(AuthMiddleware(app))
+	authorized.GET("/ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	authorized.DELETE("/auth/sessions/:id", app.deleteSession)
+
+	admin := r.Group("/api/admin")
+	admin.Use(AuthMiddleware(app), RequireAdmin)
+	admin.GET("/ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+
+	return app, r
+}
+
+func doJSON(t *testing.T, r *gin.Engine, method, path string, body any, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func registerUser(t *testing.T, r *gin.Engine, name string) authResponse {
+	t.Helper()
+	rec := doJSON(t, r, http.MethodPost, "/api/auth/register", RegisterDTO{Name: name, Password: "hunter2"}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register %s: expected 200, got %d (%s)", name, rec.Code, rec.Body.String())
+	}
+	var resp authResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestRegisterIssuesUsableToken(t *testing.T) {
+	_, r := setupAuthTestApp(t)
+	resp := registerUser(t, r, "alice")
+
+	if resp.Token == "" {
+		t.Fatal("expected a non-empty token from register")
+	}
+
+	rec := doJSON(t, r, http.MethodGet, "/api/ping", nil, resp.Token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with freshly issued token, got %d", rec.Code)
+	}
+}
+
+func TestLoginIssuesUsableToken(t *testing.T) {
+	_, r := setupAuthTestApp(t)
+	registerUser(t, r, "bob")
+
+	rec := doJSON(t, r, http.MethodPost, "/api/auth/login", LoginDTO{Name: "bob", Password: "hunter2"}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp authResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = doJSON(t, r, http.MethodGet, "/api/ping", nil, resp.Token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with login token, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_MissingToken(t *testing.T) {
+	_, r := setupAuthTestApp(t)
+	rec := doJSON(t, r, http.MethodGet, "/api/ping", nil, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with no Authorization header, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddleware_ExpiredToken(t *testing.T) {
+	app, r := setupAuthTestApp(t)
+	user := registerUser(t, r, "carol")
+
+	token, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := Session{
+		UserID:    user.User.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}
+	if err := app.DB.Create(&expired).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doJSON(t, r, http.MethodGet, "/api/ping", nil, token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with an expired token, got %d", rec.Code)
+	}
+}
+
+func TestDeleteSessionRevokesToken(t *testing.T) {
+	app, r := setupAuthTestApp(t)
+	user := registerUser(t, r, "dave")
+
+	var session Session
+	if err := app.DB.Where("user_id = ?", user.User.ID).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doJSON(t, r, http.MethodDelete, "/api/auth/sessions/"+itoaForTest(session.ID), nil, user.Token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 revoking own session, got %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(t, r, http.MethodGet, "/api/ping", nil, user.Token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after revocation, got %d", rec.Code)
+	}
+}
+
+func TestDeleteSessionForbiddenForOtherUser(t *testing.T) {
+	app, r := setupAuthTestApp(t)
+	owner := registerUser(t, r, "erin")
+	intruder := registerUser(t, r, "frank")
+
+	var session Session
+	if err := app.DB.Where("user_id = ?", owner.User.ID).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doJSON(t, r, http.MethodDelete, "/api/auth/sessions/"+itoaForTest(session.ID), nil, intruder.Token)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 revoking someone else's session, got %d (%s)", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRequireAdminForbidsRegularUser(t *testing.T) {
+	_, r := setupAuthTestApp(t)
+	user := registerUser(t, r, "grace")
+
+	rec := doJSON(t, r, http.MethodGet, "/api/admin/ping", nil, user.Token)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin hitting an admin route, got %d", rec.Code)
+	}
+}
+
+func itoaForTest(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NainaSingh31/carbon-footprint-tracker/backend/factors"
+)
+
+// resolveEmission computes the emission for one activity via the
+// factors resolver, returning the kg figure plus the subtype/region it
+// resolved against (so the caller can persist them on the Activity row).
+//
+// category/type-specific quirks that don't belong in the factors
+// package itself (carpool division, food/shopping being per-day or
+// per-spend rather than per-unit-quantity) are handled here.
+func resolveEmission(resolver *factors.Resolver, category, typ string, qty float64, unit string, meta map[string]any, region string) (kg float64, subtype string, usedRegion string, err error) {
+	category = strings.ToLower(category)
+	typ = strings.ToLower(typ)
+
+	if region == "" {
+		region = factors.RegionGlobal
+	}
+	subtype = metaSubtype(category, typ, meta)
+
+	// "other" never resolves against a factor: it's a direct emission
+	// entry (quantity already in kgCO2e/kg) or, for any other unit,
+	// nothing we can convert. Handle it before the resolver call so a
+	// missing "other" factor entry can never turn into a 422.
+	if category == "other" {
+		if strings.ToLower(unit) == "kgco2e" || strings.ToLower(unit) == "kg" {
+			return round2(qty), subtype, region, nil
+		}
+		return 0.0, subtype, region, nil
+	}
+
+	q := factors.Query{Category: category, Type: typ, Region: region, Subtype: subtype}
+	f, resolveErr := resolver.Resolve(q)
+	if resolveErr != nil {
+		// bike/walk legitimately have a zero factor with no subtype; any
+		// other miss is a real "we don't know this category/type" error.
+		if category == "transport" && (typ == "bike" || typ == "walk") {
+			return 0.0, subtype, region, nil
+		}
+		return 0.0, subtype, region, fmt.Errorf("resolve emission factor: %w", resolveErr)
+	}
+
+	switch category {
+	case "transport":
+		distance := qty
+		if passengers, ok := meta["passengers"].(float64); ok && passengers > 1 {
+			distance = qty / passengers // carpool: split the trip's emission across riders
+		}
+		return round2(distance * f.KgPerUnit), subtype, f.Region, nil
+	case "energy":
+		return round2(qty * f.KgPerUnit), subtype, f.Region, nil
+	case "food":
+		return round2(f.KgPerUnit), subtype, f.Region, nil // per-day factor, qty is informational
+	case "shopping":
+		return round2(qty * f.KgPerUnit), subtype, f.Region, nil
+	default:
+		return 0.0, subtype, f.Region, nil
+	}
+}
+
+// metaSubtype derives the factors-package subtype key from the free-form
+// Meta blob a client sent. "transport"/"car" builds its subtype from
+// fuel_type and vehicle_size; everything else uses Meta's "subtype"
+// string if present, and an empty subtype otherwise.
+func metaSubtype(category, typ string, meta map[string]any) string {
+	if category != "transport" || typ != "car" {
+		if s, ok := meta["subtype"].(string); ok {
+			return s
+		}
+		return ""
+	}
+
+	fuel, _ := meta["fuel_type"].(string)
+	size, _ := meta["vehicle_size"].(string)
+	fuel = strings.ToLower(strings.TrimSpace(fuel))
+	size = strings.ToLower(strings.TrimSpace(size))
+	if fuel == "" {
+		fuel = "petrol"
+	}
+	if size == "" {
+		size = "medium"
+	}
+	if fuel == "ev" {
+		return "ev_" + size
+	}
+	return fuel + "_" + size
+}
@@ -0,0 +1,79 @@
+// Package factors resolves emission factors (kg CO2e per unit) for an
+// activity, given its category/type plus optional region and subtype
+// hints. It replaces the single set of hard-coded global constants the
+// backend used to carry: grid intensity and vehicle efficiency vary a
+// lot by country and vehicle, and callers need to know which source a
+// number came from.
+package factors
+
+import "fmt"
+
+// EmissionFactor is one resolved factor, with enough provenance attached
+// that a caller (or the /api/factors endpoint) can explain where a
+// number came from.
+type EmissionFactor struct {
+	Category  string  `json:"category"`
+	Type      string  `json:"type"`
+	Region    string  `json:"region"`
+	Subtype   string  `json:"subtype"`
+	Year      int     `json:"year"`
+	KgPerUnit float64 `json:"kg_per_unit"`
+	Unit      string  `json:"unit"`
+	Source    string  `json:"source"` // provider name that resolved this factor
+}
+
+// Query is the lookup key passed to a FactorProvider. A zero Year means
+// "any year". Region and Subtype are compared as given: an empty Subtype
+// matches only entries without a subtype, and an empty Region matches no
+// regional entry (StaticProvider then falls back to RegionGlobal).
+type Query struct {
+	Category string
+	Type     string
+	Region   string
+	Subtype  string
+	Year     int
+}
+
+// RegionGlobal is the wildcard region used for factors that don't vary
+// by country (most food and shopping factors, for instance).
+const RegionGlobal = "global"
+
+// FactorProvider resolves a Query to a factor. It returns ok=false (not
+// an error) when it simply doesn't have data for the query, so a
+// Resolver can fall through to the next provider.
+type FactorProvider interface {
+	Name() string
+	Resolve(q Query) (EmissionFactor, bool)
+}
+
+// Resolver tries each provider in order and returns the first hit. This
+// mirrors the fallback chain callers want: a user override first (it's
+// explicit, so it should win), a live HTTP source next, and the bundled
+// static dataset as the backstop.
+type Resolver struct {
+	providers []FactorProvider
+}
+
+func NewResolver(providers ...FactorProvider) *Resolver {
+	return &Resolver{providers: providers}
+}
+
+func (r *Resolver) Resolve(q Query) (EmissionFactor, error) {
+	for _, p := range r.providers {
+		if f, ok := p.Resolve(q); ok {
+			f.Source = p.Name()
+			return f, nil
+		}
+	}
+	return EmissionFactor{}, fmt.Errorf("factors: no provider has data for %s/%s (region=%s subtype=%s)", q.Category, q.Type, q.Region, q.Subtype)
+}
+
+// Sources returns provider provenance in resolution order, for
+// GET /api/factors/sources.
+func (r *Resolver) Sources() []string {
+	names := make([]string, len(r.providers))
+	for i, p := range r.providers {
+		names[i] = p.Name()
+	}
+	return names
+}
@@ -0,0 +1,90 @@
+package factors
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileProvider lets an operator override or extend factors without a
+// redeploy: point it at a JSON file shaped like dataset.json and it
+// reloads whenever the file's mtime changes. A file that is missing or
+// fails to parse at startup is treated as "no data" rather than an error
+// so the resolver falls through to the next provider; once a file has
+// loaded, a later missing or unparsable file leaves those entries in use.
+type FileProvider struct {
+	path string
+
+	mu      sync.RWMutex
+	entries []EmissionFactor
+	modTime time.Time
+}
+
+// NewFileProvider starts watching path on a poll interval. The initial
+// load happens synchronously so the first request after startup already
+// sees any override that exists.
+func NewFileProvider(path string, pollInterval time.Duration, stop <-chan struct{}) *FileProvider {
+	p := &FileProvider{path: path}
+	p.reload()
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.reload()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+func (p *FileProvider) reload() {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return // no override file configured/present
+	}
+
+	p.mu.RLock()
+	unchanged := info.ModTime().Equal(p.modTime)
+	p.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		log.Printf("factors: could not read override file %s: %v", p.path, err)
+		return
+	}
+	var entries []EmissionFactor
+	if err := json.Unmarshal(b, &entries); err != nil {
+		log.Printf("factors: ignoring malformed override file %s: %v", p.path, err)
+		return
+	}
+
+	p.mu.Lock()
+	p.entries = entries
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+	log.Printf("factors: loaded %d override factor(s) from %s", len(entries), p.path)
+}
+
+func (p *FileProvider) Name() string { return "file:" + p.path }
+
+func (p *FileProvider) Resolve(q Query) (EmissionFactor, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, e := range p.entries {
+		if e.Category == q.Category && e.Type == q.Type && e.Region == q.Region && e.Subtype == q.Subtype {
+			return e, true
+		}
+	}
+	return EmissionFactor{}, false
+}
@@ -0,0 +1,107 @@
+package factors
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPProvider fetches live grid-intensity figures from a configurable
+// endpoint: a base URL plus a token read from the environment,
+// short-TTL response caching, and a fallback to "no data" (never an
+// error) so the resolver drops through to StaticProvider on any trouble.
+//
+// It only answers energy/electricity queries; everything else is left
+// to the other providers.
+type HTTPProvider struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedIntensity
+}
+
+type cachedIntensity struct {
+	factor    EmissionFactor
+	fetchedAt time.Time
+}
+
+// gridIntensityResponse is the shape expected back from the endpoint:
+// {"region": "US", "kg_per_kwh": 0.386, "year": 2024}
+type gridIntensityResponse struct {
+	Region   string  `json:"region"`
+	KgPerKWh float64 `json:"kg_per_kwh"`
+	Year     int     `json:"year"`
+}
+
+func NewHTTPProvider(baseURL, token string, ttl time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL: baseURL,
+		token:   token,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		ttl:     ttl,
+		cache:   map[string]cachedIntensity{},
+	}
+}
+
+func (p *HTTPProvider) Name() string { return "http:" + p.baseURL }
+
+func (p *HTTPProvider) Resolve(q Query) (EmissionFactor, bool) {
+	if q.Category != "energy" || q.Type != "electricity" || q.Region == "" {
+		return EmissionFactor{}, false
+	}
+
+	p.mu.Lock()
+	if cached, ok := p.cache[q.Region]; ok && time.Since(cached.fetchedAt) < p.ttl {
+		p.mu.Unlock()
+		return cached.factor, true
+	}
+	p.mu.Unlock()
+
+	factor, ok := p.fetch(q.Region)
+	if !ok {
+		return EmissionFactor{}, false
+	}
+
+	p.mu.Lock()
+	p.cache[q.Region] = cachedIntensity{factor: factor, fetchedAt: time.Now()}
+	p.mu.Unlock()
+
+	return factor, true
+}
+
+func (p *HTTPProvider) fetch(region string) (EmissionFactor, bool) {
+	req, err := http.NewRequest(http.MethodGet, p.baseURL+"/grid-intensity?region="+region, nil)
+	if err != nil {
+		return EmissionFactor{}, false
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return EmissionFactor{}, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return EmissionFactor{}, false
+	}
+
+	var body gridIntensityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return EmissionFactor{}, false
+	}
+
+	return EmissionFactor{
+		Category:  "energy",
+		Type:      "electricity",
+		Region:    region,
+		Year:      body.Year,
+		KgPerUnit: body.KgPerKWh,
+		Unit:      "kWh",
+	}, true
+}
@@ -0,0 +1,75 @@
+package factors
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strconv"
+)
+
+//go:embed dataset.json
+var embeddedDataset []byte
+
+// StaticProvider serves the bundled dataset.json: per-country grid
+// intensities and vehicle subtypes, covering every category the app
+// supports. It is the backstop provider: it falls back through region
+// and subtype down to a "global" entry with no subtype. It returns
+// ok=false when none of those tiers has an entry, e.g. for a car subtype
+// the dataset lacks, since every car entry carries a subtype.
+type StaticProvider struct {
+	entries []EmissionFactor
+}
+
+// NewStaticProvider loads the dataset embedded at build time. An error
+// here means the embedded JSON is malformed, which should only happen if
+// someone hand-edited dataset.json into invalid shape.
+func NewStaticProvider() (*StaticProvider, error) {
+	var entries []EmissionFactor
+	if err := json.Unmarshal(embeddedDataset, &entries); err != nil {
+		return nil, err
+	}
+	return &StaticProvider{entries: entries}, nil
+}
+
+func (p *StaticProvider) Name() string { return "static:" + strconv.Itoa(len(p.entries)) + "-entries" }
+
+func (p *StaticProvider) Resolve(q Query) (EmissionFactor, bool) {
+	// Try, in order of specificity: exact region+subtype, global+subtype,
+	// exact region with no subtype, global with no subtype. Within each
+	// tier prefer the newest year <= the requested year (or the newest
+	// overall if no year was requested).
+	tiers := [][2]string{
+		{q.Region, q.Subtype},
+		{RegionGlobal, q.Subtype},
+		{q.Region, ""},
+		{RegionGlobal, ""},
+	}
+
+	for _, tier := range tiers {
+		region, subtype := tier[0], tier[1]
+		if region == "" {
+			continue
+		}
+		if best, ok := p.bestMatch(q.Category, q.Type, region, subtype, q.Year); ok {
+			return best, true
+		}
+	}
+	return EmissionFactor{}, false
+}
+
+func (p *StaticProvider) bestMatch(category, typ, region, subtype string, year int) (EmissionFactor, bool) {
+	var best EmissionFactor
+	found := false
+	for _, e := range p.entries {
+		if e.Category != category || e.Type != typ || e.Region != region || e.Subtype != subtype {
+			continue
+		}
+		if year != 0 && e.Year > year {
+			continue
+		}
+		if !found || e.Year > best.Year {
+			best = e
+			found = true
+		}
+	}
+	return best, found
+}
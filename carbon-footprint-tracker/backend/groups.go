@@ -0,0 +1,415 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Group lets a household/team/classroom track collective footprint.
+// Activity data stays owned by the individual who logged it — groups
+// only ever read across members, never mutate their activities.
+type Group struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `json:"name"`
+	OwnerUserID uint      `json:"owner_user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GroupMember rows are how a user belongs to a group; a user can belong
+// to many groups, so this is a many-to-many join, not a FK on User.
+type GroupMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GroupID   uint      `gorm:"uniqueIndex:idx_group_member" json:"group_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_group_member" json:"user_id"`
+	Role      string    `json:"role"` // "owner" or "member"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GroupInvite struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	GroupID   uint       `gorm:"index" json:"group_id"`
+	Token     string     `gorm:"uniqueIndex" json:"-"`
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// GroupGoal is a target for the group (or one category within it) over
+// a fixed period, e.g. "under 500kg total for Q1".
+type GroupGoal struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	GroupID     uint      `gorm:"index" json:"group_id"`
+	Category    string    `json:"category"` // blank means "all categories"
+	TargetKg    float64   `json:"target_kg"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const groupInviteTTL = 7 * 24 * time.Hour
+
+type CreateGroupDTO struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type CreateGroupInviteDTO struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type CreateGroupGoalDTO struct {
+	Category    string  `json:"category"`
+	TargetKg    float64 `json:"target_kg" binding:"required"`
+	PeriodStart string  `json:"period_start" binding:"required"`
+	PeriodEnd   string  `json:"period_end" binding:"required"`
+}
+
+func (a *App) createGroup(c *gin.Context) {
+	var dto CreateGroupDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := currentUserID(c)
+	group := Group{Name: dto.Name, OwnerUserID: userID}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return tx.Create(&GroupMember{GroupID: group.ID, UserID: userID, Role: "owner"}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// groupMemberRole returns the caller's role in a group, or ok=false if
+// they aren't a member at all.
+func (a *App) groupMemberRole(groupID, userID uint) (role string, ok bool) {
+	var member GroupMember
+	err := a.DB.Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error
+	if err != nil {
+		return "", false
+	}
+	return member.Role, true
+}
+
+func (a *App) createGroupInvite(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group id"})
+		return
+	}
+
+	role, isMember := a.groupMemberRole(uint(groupID), currentUserID(c))
+	if !isMember || role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the group owner can invite members"})
+		return
+	}
+
+	var dto CreateGroupInviteDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	invite := GroupInvite{
+		GroupID:   uint(groupID),
+		Token:     hex.EncodeToString(raw),
+		Email:     dto.Email,
+		ExpiresAt: time.Now().Add(groupInviteTTL),
+	}
+	if err := a.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, invite)
+}
+
+func (a *App) joinGroup(c *gin.Context) {
+	token := c.Param("token")
+	userID := currentUserID(c)
+
+	var invite GroupInvite
+	err := a.DB.Where("token = ? AND used_at IS NULL AND expires_at > ?", token, time.Now()).First(&invite).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invite not found, used, or expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&invite).Update("used_at", &now).Error; err != nil {
+			return err
+		}
+		return tx.FirstOrCreate(&GroupMember{}, GroupMember{GroupID: invite.GroupID, UserID: userID, Role: "member"}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"group_id": invite.GroupID})
+}
+
+func (a *App) groupMemberIDs(groupID uint) ([]uint, error) {
+	var memberIDs []uint
+	err := a.DB.Model(&GroupMember{}).Where("group_id = ?", groupID).Pluck("user_id", &memberIDs).Error
+	return memberIDs, err
+}
+
+// requireGroupMembership is the common guard for every /groups/:id/*
+// read endpoint: the caller must belong to the group.
+func (a *App) requireGroupMembership(c *gin.Context) (groupID uint, ok bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group id"})
+		return 0, false
+	}
+	if _, isMember := a.groupMemberRole(uint(id), currentUserID(c)); !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a member of this group"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// groupSummary aggregates every member's emissions across the requested
+// range using the same rollup tables /api/summary reads from.
+func (a *App) groupSummary(c *gin.Context) {
+	groupID, ok := a.requireGroupMembership(c)
+	if !ok {
+		return
+	}
+
+	from, to, granularity, ok := parseSummaryRange(c)
+	if !ok {
+		return
+	}
+
+	memberIDs, err := a.groupMemberIDs(groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := querySummaryFromRollupsForUsers(a.DB, from, to, granularity, memberIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+type leaderboardEntry struct {
+	UserID        uint    `json:"user_id"`
+	Name          string  `json:"name"`
+	EmissionKg    float64 `json:"emission_kg"`
+	ActivityCount int64   `json:"activity_count"`
+}
+
+// groupLeaderboard ranks members by lowest total emission over the
+// period (each member is one "capita"), breaking ties by fewer logged
+// activities — the member who got there with less logging wins the tie.
+func (a *App) groupLeaderboard(c *gin.Context) {
+	groupID, ok := a.requireGroupMembership(c)
+	if !ok {
+		return
+	}
+
+	from, to := periodRange(c.DefaultQuery("period", "week"))
+
+	memberIDs, err := a.groupMemberIDs(groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries := make([]leaderboardEntry, 0, len(memberIDs))
+	for _, uid := range memberIDs {
+		var user User
+		if err := a.DB.First(&user, uid).Error; err != nil {
+			continue
+		}
+
+		// Sum and count over the exact same calendar-day window: the
+		// rollup buckets whole days, so the raw-activity count must be
+		// bounded the same way (inclusive of all of "to", not cut off at
+		// its midnight) or today's activities would count without their
+		// emission being counted, or vice versa.
+		dayFrom := dayBucket(from)
+		dayToExclusive := dayBucket(to).AddDate(0, 0, 1)
+
+		var total float64
+		a.DB.Model(&DailyRollup{}).
+			Where("user_id = ? AND date >= ? AND date < ?", uid, dayFrom, dayToExclusive).
+			Select("COALESCE(SUM(kg), 0)").Scan(&total)
+
+		var count int64
+		a.DB.Model(&Activity{}).Where("user_id = ? AND date >= ? AND date < ?", uid, dayFrom, dayToExclusive).Count(&count)
+
+		entries = append(entries, leaderboardEntry{UserID: uid, Name: user.Name, EmissionKg: round2(total), ActivityCount: count})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].EmissionKg != entries[j].EmissionKg {
+			return entries[i].EmissionKg < entries[j].EmissionKg
+		}
+		return entries[i].ActivityCount < entries[j].ActivityCount
+	})
+
+	c.JSON(http.StatusOK, gin.H{"period": c.DefaultQuery("period", "week"), "leaderboard": entries})
+}
+
+// periodRange turns a leaderboard "period" query param into a concrete
+// range ending today.
+func periodRange(period string) (from, to time.Time) {
+	today := dayBucket(time.Now())
+	switch period {
+	case "month":
+		return today.AddDate(0, -1, 0), today
+	default: // "week"
+		return today.AddDate(0, 0, -6), today
+	}
+}
+
+func (a *App) createGroupGoal(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group id"})
+		return
+	}
+	role, isMember := a.groupMemberRole(uint(groupID), currentUserID(c))
+	if !isMember || role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the group owner can set goals"})
+		return
+	}
+
+	var dto CreateGroupGoalDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, err := time.Parse("2006-01-02", dto.PeriodStart)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_start"})
+		return
+	}
+	end, err := time.Parse("2006-01-02", dto.PeriodEnd)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_end"})
+		return
+	}
+
+	goal := GroupGoal{
+		GroupID:     uint(groupID),
+		Category:    dto.Category,
+		TargetKg:    dto.TargetKg,
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+	if err := a.DB.Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, goal)
+}
+
+type goalProgress struct {
+	GoalID        uint    `json:"goal_id"`
+	Category      string  `json:"category"`
+	TargetKg      float64 `json:"target_kg"`
+	ActualKg      float64 `json:"actual_kg"`
+	PercentToGoal float64 `json:"percent_to_goal"`
+	ProjectedKg   float64 `json:"projected_kg"` // linear extrapolation to period end
+	DaysElapsed   int     `json:"days_elapsed"`
+	DaysTotal     int     `json:"days_total"`
+}
+
+// groupGoalsProgress reports, for every goal the group currently has,
+// how far members are into it and where a straight-line projection of
+// their current pace lands by the period's end.
+func (a *App) groupGoalsProgress(c *gin.Context) {
+	groupID, ok := a.requireGroupMembership(c)
+	if !ok {
+		return
+	}
+
+	var goals []GroupGoal
+	if err := a.DB.Where("group_id = ?", groupID).Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	memberIDs, err := a.groupMemberIDs(groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	results := make([]goalProgress, 0, len(goals))
+	for _, goal := range goals {
+		windowEnd := now
+		if windowEnd.After(goal.PeriodEnd) {
+			windowEnd = goal.PeriodEnd
+		}
+
+		actual, err := sumActivityEmissions(a.DB, memberIDs, goal.Category, goal.PeriodStart, windowEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		daysElapsed := int(windowEnd.Sub(goal.PeriodStart).Hours()/24) + 1
+		daysTotal := int(goal.PeriodEnd.Sub(goal.PeriodStart).Hours()/24) + 1
+		if daysElapsed < 1 {
+			daysElapsed = 1
+		}
+
+		projected := actual / float64(daysElapsed) * float64(daysTotal)
+
+		results = append(results, goalProgress{
+			GoalID:        goal.ID,
+			Category:      goal.Category,
+			TargetKg:      goal.TargetKg,
+			ActualKg:      round2(actual),
+			PercentToGoal: round2(actual / goal.TargetKg * 100),
+			ProjectedKg:   round2(projected),
+			DaysElapsed:   daysElapsed,
+			DaysTotal:     daysTotal,
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func sumActivityEmissions(db *gorm.DB, userIDs []uint, category string, from, to time.Time) (float64, error) {
+	query := db.Model(&Activity{}).Where("user_id IN ? AND date BETWEEN ? AND ?", userIDs, from, to)
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	var total float64
+	err := query.Select("COALESCE(SUM(emission_kg), 0)").Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Mailer sends a rendered report as an email attachment. SMTPMailer is
+// the default; NoopMailer is used in tests and whenever SMTP isn't
+// configured, so scheduling a report never fails a request just because
+// mail isn't set up.
+type Mailer interface {
+	Send(to, subject, body string, attachment []byte, attachmentName, contentType string) error
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	From     string
+	Username string
+	Password string
+}
+
+// NewSMTPMailerFromEnv reads SMTP_HOST/SMTP_PORT/SMTP_FROM/SMTP_USERNAME/
+// SMTP_PASSWORD. If SMTP_HOST is unset, it returns a NoopMailer instead.
+func NewSMTPMailerFromEnv() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return NoopMailer{}
+	}
+	return SMTPMailer{
+		Host:     host,
+		Port:     envDefault("SMTP_PORT", "587"),
+		From:     envDefault("SMTP_FROM", "reports@carbon-footprint-tracker.local"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+func envDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// stripCRLF removes bare/embedded CR and LF from a value headed for a
+// raw email header. to/subject/attachmentName all ultimately come from
+// user input (ScheduledReport.Email / .Preset), and this message is
+// built with fmt.Sprintf rather than a MIME header writer, so a
+// newline in any of them would inject arbitrary headers or body
+// content into the outgoing mail.
+func stripCRLF(s string) string {
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.ReplaceAll(s, "\n", "")
+}
+
+func (m SMTPMailer) Send(to, subject, body string, attachment []byte, attachmentName, contentType string) error {
+	to = stripCRLF(to)
+	subject = stripCRLF(subject)
+	attachmentName = stripCRLF(attachmentName)
+
+	boundary := "carbon-footprint-tracker-boundary"
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n"+
+		"--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n"+
+		"--%s\r\nContent-Type: %s\r\nContent-Disposition: attachment; filename=%q\r\nContent-Transfer-Encoding: base64\r\n\r\n%s\r\n"+
+		"--%s--\r\n",
+		m.From, to, subject, boundary,
+		boundary, body,
+		boundary, contentType, attachmentName, base64.StdEncoding.EncodeToString(attachment),
+		boundary,
+	)
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+}
+
+// NoopMailer discards the message — used in tests and local dev runs
+// without SMTP configured.
+type NoopMailer struct{}
+
+func (NoopMailer) Send(to, subject, body string, attachment []byte, attachmentName, contentType string) error {
+	log.Printf("mailer: (noop) would send %q to %s with %d byte attachment %s", subject, to, len(attachment), attachmentName)
+	return nil
+}
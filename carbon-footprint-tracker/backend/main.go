@@ -2,8 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	"strings"
 	"strconv"
@@ -12,21 +16,34 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+
+	"github.com/NainaSingh31/carbon-footprint-tracker/backend/factors"
 )
 
+// rollupBackfillInterval controls how often the background worker
+// re-sums the last 48h of activities into the rollup tables.
+const rollupBackfillInterval = 10 * time.Minute
+
+const summaryCachePath = "summary_cache.json"
+
 type User struct {
-	ID       uint   `gorm:"primaryKey" json:"id"`
-	Name     string `json:"name"`
-	Location string `json:"location"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"uniqueIndex" json:"name"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"` // "user" or "admin"
+	Location     string    `json:"location"` // ISO-3166 country code, e.g. "US"; drives the default factors region
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Activity struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index" json:"user_id"`
 	Category   string    `json:"category"` // transport, energy, food, shopping, other
 	Type       string    `json:"type"`     // e.g., car, bus, electricity, vegetarian_day, etc.
 	Quantity   float64   `json:"quantity"` // numeric input for the type (e.g., distance_km, kWh, spend)
 	Unit       string    `json:"unit"`
+	Subtype    string    `json:"subtype"` // resolved factors subtype, e.g. "petrol_medium" (blank if the category has none)
+	Region     string    `json:"region"`  // factors region the emission was resolved against
 	Meta       string    `json:"meta"`     // raw JSON string for any extra fields
 	EmissionKg float64   `json:"emission_kg"`
 	Date       time.Time `json:"date"`
@@ -38,8 +55,12 @@ type CreateActivityDTO struct {
 	Type     string   `json:"type" binding:"required"`
 	Quantity float64  `json:"quantity"`
 	Unit     string   `json:"unit"`
-	Meta     gin.H    `json:"meta"`
-	Date     string   `json:"date"` // ISO date YYYY-MM-DD (optional); defaults to today
+	// Meta carries category-specific hints for the factors resolver:
+	// "fuel_type" (petrol|diesel|ev) and "vehicle_size" (small|medium|large)
+	// for transport/car, "passengers" to split a carpooled trip's emission,
+	// and "region" to override the account's default country code.
+	Meta gin.H  `json:"meta"`
+	Date string `json:"date"` // ISO date YYYY-MM-DD (optional); defaults to today
 }
 
 type SummaryResponse struct {
@@ -56,45 +77,136 @@ type DailyPoint struct {
 }
 
 type App struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	cache    *summaryCache
+	Resolver *factors.Resolver
+}
+
+// factorsOverridePollInterval controls how often FileProvider checks the
+// override file's mtime for changes.
+const factorsOverridePollInterval = 30 * time.Second
+
+// httpFactorsCacheTTL bounds how long a live grid-intensity lookup is
+// reused before HTTPProvider refetches it.
+const httpFactorsCacheTTL = 1 * time.Hour
+
+func buildResolver(stop <-chan struct{}) *factors.Resolver {
+	static, err := factors.NewStaticProvider()
+	if err != nil {
+		panic(err) // malformed embedded dataset, not something that should happen at runtime
+	}
+
+	providers := []factors.FactorProvider{}
+
+	if overridePath := os.Getenv("FACTORS_OVERRIDE_FILE"); overridePath != "" {
+		providers = append(providers, factors.NewFileProvider(overridePath, factorsOverridePollInterval, stop))
+	}
+
+	if baseURL := os.Getenv("FACTORS_HTTP_BASE_URL"); baseURL != "" {
+		token := os.Getenv("FACTORS_HTTP_TOKEN")
+		providers = append(providers, factors.NewHTTPProvider(baseURL, token, httpFactorsCacheTTL))
+	}
+
+	providers = append(providers, static)
+	return factors.NewResolver(providers...)
+}
+
+// corsAllowedOrigins reads a comma-separated allowlist from
+// CORS_ALLOWED_ORIGINS. Auth guards who can act; this guards which
+// browsers are even allowed to ask, so it can no longer be "*" by
+// default once accounts hold private data.
+func corsAllowedOrigins() []string {
+	raw := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if strings.TrimSpace(raw) == "" {
+		return []string{"http://localhost:5173"}
+	}
+	var origins []string
+	for _, o := range strings.Split(raw, ",") {
+		if o := strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
 }
 
 func main() {
 	db, err := gorm.Open(sqlite.Open("app.db"), &gorm.Config{})
 	if err != nil { panic(err) }
-	db.AutoMigrate(&User{}, &Activity{})
+	db.AutoMigrate(&User{}, &Activity{}, &DailyRollup{}, &HourlyRollup{}, &Session{}, &ScheduledReport{}, &Group{}, &GroupMember{}, &GroupInvite{}, &GroupGoal{})
 
-	app := &App{DB: db}
+	cache := newSummaryCache(summaryCachePath)
+	cache.loadFromDisk()
+
+	stopRollupWorker := make(chan struct{})
+	startRollupWorker(db, cache, rollupBackfillInterval, stopRollupWorker)
+
+	app := &App{DB: db, cache: cache, Resolver: buildResolver(stopRollupWorker)}
+
+	mailer := NewSMTPMailerFromEnv()
+	startReportScheduler(app, mailer, stopRollupWorker)
 
 	r := gin.Default()
 
-	// CORS for local dev (frontend on 5173 or a file://)
 	r.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"*"},
+		AllowOrigins: corsAllowedOrigins(),
 		AllowMethods: []string{"GET","POST","DELETE","OPTIONS"},
-		AllowHeaders: []string{"Origin","Content-Type","Accept"},
+		AllowHeaders: []string{"Origin","Content-Type","Accept","Authorization"},
 	}))
 
 	r.GET("/api/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
 
-	// seed a default user if none exists (no auth for simplicity)
-	var count int64
-	db.Model(&User{}).Count(&count)
-	if count == 0 {
-		db.Create(&User{Name: "Demo User", Location: "Earth"})
+	// seed a demo admin so a fresh DB (and every activity written before
+	// this migration) has an owner.
+	var demoUser User
+	if err := db.Where("name = ?", "Demo User").First(&demoUser).Error; err == gorm.ErrRecordNotFound {
+		demoUser = User{Name: "Demo User", Role: "admin", Location: factors.RegionGlobal}
+		db.Create(&demoUser)
+	}
+	if err := assignOrphanActivitiesToUser(db, demoUser.ID); err != nil {
+		panic(err)
 	}
 
-	r.GET("/api/activities", app.listActivities)
-	r.POST("/api/activities", app.createActivity)
-	r.DELETE("/api/activities/:id", app.deleteActivity)
-	r.GET("/api/summary", app.summary)
+	r.POST("/api/auth/register", app.register)
+	r.POST("/api/auth/login", app.login)
+
+	authorized := r.Group("/api")
+	authorized.Use(AuthMiddleware(app))
+	authorized.GET("/activities", app.listActivities)
+	authorized.POST("/activities", app.createActivity)
+	authorized.DELETE("/activities/:id", app.deleteActivity)
+	authorized.GET("/summary", app.summary)
+	authorized.GET("/factors", app.getFactor)
+	authorized.GET("/factors/sources", app.factorSources)
+	authorized.DELETE("/auth/sessions/:id", app.deleteSession)
+	authorized.GET("/reports", app.getReports)
+	authorized.POST("/reports/schedule", app.scheduleReport)
+	authorized.POST("/groups", app.createGroup)
+	authorized.POST("/groups/join/:token", app.joinGroup)
+	authorized.POST("/groups/:id/invites", app.createGroupInvite)
+	authorized.POST("/groups/:id/goals", app.createGroupGoal)
+	authorized.GET("/groups/:id/summary", app.groupSummary)
+	authorized.GET("/groups/:id/leaderboard", app.groupLeaderboard)
+	authorized.GET("/groups/:id/goals/progress", app.groupGoalsProgress)
+
+	admin := r.Group("/api/admin")
+	admin.Use(AuthMiddleware(app), RequireAdmin)
+	admin.GET("/summary", app.adminSummary)
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		close(stopRollupWorker)
+		cache.saveToDisk()
+		os.Exit(0)
+	}()
 
 	r.Run(":8080")
 }
 
 func (a *App) listActivities(c *gin.Context) {
 	var items []Activity
-	if err := a.DB.Order("date asc, id asc").Find(&items).Error; err != nil {
+	if err := a.DB.Where("user_id = ?", currentUserID(c)).Order("date asc, id asc").Find(&items).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -121,8 +233,13 @@ func (a *App) createActivity(c *gin.Context) {
 		}
 	}
 
-	// compute emission
-	emission := computeEmission(dto.Category, dto.Type, dto.Quantity, dto.Unit, dto.Meta)
+	userID := currentUserID(c)
+	region := a.resolveRegion(dto.Meta, userID)
+	emission, subtype, resolvedRegion, err := resolveEmission(a.Resolver, dto.Category, dto.Type, dto.Quantity, dto.Unit, dto.Meta, region)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
 
 	metaJSON := "{}"
 	if dto.Meta != nil {
@@ -132,18 +249,28 @@ func (a *App) createActivity(c *gin.Context) {
 	}
 
 	item := Activity{
+		UserID: userID,
 		Category: dto.Category,
 		Type: dto.Type,
 		Quantity: dto.Quantity,
 		Unit: dto.Unit,
+		Subtype: subtype,
+		Region: resolvedRegion,
 		Meta: metaJSON,
 		EmissionKg: emission,
 		Date: d,
 	}
-	if err := a.DB.Create(&item).Error; err != nil {
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		return applyRollupDelta(tx, item.Date, item.Category, item.UserID, item.EmissionKg)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	a.cache.invalidateAll()
 	c.JSON(http.StatusOK, item)
 }
 
@@ -154,139 +281,155 @@ func (a *App) deleteActivity(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if err := a.DB.Delete(&Activity{}, id).Error; err != nil {
+	userID := currentUserID(c)
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		var item Activity
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&item).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&Activity{}, id).Error; err != nil {
+			return err
+		}
+		return applyRollupDelta(tx, item.Date, item.Category, item.UserID, -item.EmissionKg)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "activity not found"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	a.cache.invalidateAll()
 	c.JSON(http.StatusOK, gin.H{"deleted": id})
 }
 
-func (a *App) summary(c *gin.Context) {
-	fromStr := c.Query("from")
-	toStr := c.Query("to")
+// parseSummaryRange reads from/to/granularity query params shared by
+// /api/summary and /api/admin/summary.
+func parseSummaryRange(c *gin.Context) (from, to time.Time, granularity string, ok bool) {
+	granularity = strings.ToLower(c.DefaultQuery("granularity", "day"))
+	switch granularity {
+	case "hour", "day", "week", "month":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid granularity, use hour|day|week|month"})
+		return
+	}
 
-	var from, to time.Time
 	var err error
-
-	if strings.TrimSpace(fromStr) == "" {
+	if fromStr := c.Query("from"); strings.TrimSpace(fromStr) == "" {
 		from = time.Now().AddDate(0, 0, -29) // last 30 days
 	} else {
 		from, err = time.Parse("2006-01-02", fromStr)
 		if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"}); return }
 	}
 
-	if strings.TrimSpace(toStr) == "" {
+	if toStr := c.Query("to"); strings.TrimSpace(toStr) == "" {
 		to = time.Now()
 	} else {
 		to, err = time.Parse("2006-01-02", toStr)
 		if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"}); return }
 	}
 
-	var items []Activity
-	if err := a.DB.Where("date BETWEEN ? AND ?", from, to).Order("date asc").Find(&items).Error; err != nil {
+	return from, to, granularity, true
+}
+
+func (a *App) summary(c *gin.Context) {
+	from, to, granularity, ok := parseSummaryRange(c)
+	if !ok {
+		return
+	}
+
+	userID := currentUserID(c)
+	noCache := strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache")
+	key := summaryCacheKey(from.Format("2006-01-02"), to.Format("2006-01-02"), granularity) + "|" + strconv.FormatUint(uint64(userID), 10)
+
+	if !noCache {
+		if cached, ok := a.cache.get(key); ok {
+			c.JSON(http.StatusOK, cached)
+			return
+		}
+	}
+
+	resp, err := querySummaryFromRollups(a.DB, from, to, granularity, userID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	byCat := map[string]float64{}
-	total := 0.0
-	byDay := map[string]float64{}
+	a.cache.set(key, resp)
+	c.JSON(http.StatusOK, resp)
+}
 
-	for _, it := range items {
-		total += it.EmissionKg
-		byCat[it.Category] += it.EmissionKg
-		key := it.Date.Format("2006-01-02")
-		byDay[key] += it.EmissionKg
+// adminSummary lets an admin pull any single user's summary, e.g. for
+// support or moderation, via ?user_id=.
+func (a *App) adminSummary(c *gin.Context) {
+	userID64, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
 	}
 
-	// fill daily points across the range
-	points := []DailyPoint{}
-	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
-		key := d.Format("2006-01-02")
-		points = append(points, DailyPoint{Date: key, Kg: byDay[key]})
+	from, to, granularity, ok := parseSummaryRange(c)
+	if !ok {
+		return
 	}
 
-	c.JSON(http.StatusOK, SummaryResponse{
-		From: from.Format("2006-01-02"),
-		To: to.Format("2006-01-02"),
-		TotalKg: round2(total),
-		ByCategory: roundMap(byCat),
-		ByDay: points,
-	})
+	resp, err := querySummaryFromRollups(a.DB, from, to, granularity, uint(userID64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // --------------------
-// Emission calculator
+// Factors endpoints
 // --------------------
 
-func computeEmission(category, typ string, qty float64, unit string, meta map[string]any) float64 {
-	// Baseline factors (illustrative averages, kg CO2e per unit):
-	// Transport per km:
-	carPerKm := 0.192  // average car
-	busPerKm := 0.105
-	trainPerKm := 0.041
-	bikePerKm := 0.0
-	airPerKm := 0.255  // short/medium haul rough average
-
-	// Energy:
-	kWhFactor := 0.7   // kg/kWh (adjust for your grid)
-	lpgKgFactor := 3.0 // per kg LPG burned ~3 kg CO2e (simplified)
-
-	// Food (per day):
-	meatHeavy := 7.0
-	vegetarian := 3.0
-	vegan := 2.0
-
-	// Shopping (per 1000 currency units):
-	shoppingFactorPerThousand := 1.5 // kg CO2e per 1000 units of currency (very rough)
-
-	category = strings.ToLower(category)
-	typ = strings.ToLower(typ)
-	unit = strings.ToLower(unit)
-
-	switch category {
-	case "transport":
-		switch typ {
-		case "car":
-			// qty = distance km
-			return round2(qty * carPerKm)
-		case "bus":
-			return round2(qty * busPerKm)
-		case "train":
-			return round2(qty * trainPerKm)
-		case "bike", "walk":
-			return 0.0
-		case "flight":
-			return round2(qty * airPerKm)
-		}
-	case "energy":
-		switch typ {
-		case "electricity":
-			// qty = kWh
-			return round2(qty * kWhFactor)
-		case "lpg":
-			// qty = kg of LPG
-			return round2(qty * lpgKgFactor)
-		}
-	case "food":
-		switch typ {
-		case "meat_heavy_day":
-			return meatHeavy
-		case "vegetarian_day":
-			return vegetarian
-		case "vegan_day":
-			return vegan
-		}
-	case "shopping":
-		// qty = spend
-		return round2((qty / 1000.0) * shoppingFactorPerThousand)
-	case "other":
-		// if user provides a direct emission value in kg
-		if unit == "kgco2e" || unit == "kg" {
-			return round2(qty)
+// resolveRegion picks the country code an activity's factors should
+// resolve against: an explicit "region" in Meta wins, otherwise it falls
+// back to the caller's own account Location.
+func (a *App) resolveRegion(meta gin.H, userID uint) string {
+	if meta != nil {
+		if region, ok := meta["region"].(string); ok && strings.TrimSpace(region) != "" {
+			return region
 		}
 	}
-	return 0.0
+	var u User
+	if err := a.DB.First(&u, userID).Error; err != nil || u.Location == "" {
+		return factors.RegionGlobal
+	}
+	return u.Location
+}
+
+// getFactor lets a client introspect exactly which factor a given
+// category/type/region/subtype combination resolves to, and who
+// (static/file/http) it came from.
+func (a *App) getFactor(c *gin.Context) {
+	q := factors.Query{
+		Category: strings.ToLower(c.Query("category")),
+		Type:     strings.ToLower(c.Query("type")),
+		Region:   c.DefaultQuery("region", factors.RegionGlobal),
+		Subtype:  c.Query("subtype"),
+	}
+	if q.Category == "" || q.Type == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category and type are required"})
+		return
+	}
+
+	f, err := a.Resolver.Resolve(q)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+// factorSources lists provider provenance in resolution order, so users
+// can audit where the numbers behind their totals come from.
+func (a *App) factorSources(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sources": a.Resolver.Sources()})
 }
 
 // ---------------
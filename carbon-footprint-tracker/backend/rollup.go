@@ -0,0 +1,475 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// --------------------
+// Rollup tables
+// --------------------
+//
+// Activity rows are append/delete only, so we keep DailyRollup and
+// HourlyRollup in sync incrementally on write (createActivity /
+// deleteActivity), run a periodic backfill over the last 48h to correct
+// any drift, and run one full backfill at startup to fill buckets that
+// predate this feature.
+
+type DailyRollup struct {
+	ID       uint      `gorm:"primaryKey" json:"id"`
+	Date     time.Time `gorm:"uniqueIndex:idx_daily_rollup_date_category_user" json:"date"`
+	Category string    `gorm:"uniqueIndex:idx_daily_rollup_date_category_user" json:"category"`
+	UserID   uint      `gorm:"uniqueIndex:idx_daily_rollup_date_category_user" json:"user_id"`
+	Kg       float64   `json:"kg"`
+}
+
+type HourlyRollup struct {
+	ID       uint      `gorm:"primaryKey" json:"id"`
+	Hour     time.Time `gorm:"uniqueIndex:idx_hourly_rollup_hour_category_user" json:"hour"`
+	Category string    `gorm:"uniqueIndex:idx_hourly_rollup_hour_category_user" json:"category"`
+	UserID   uint      `gorm:"uniqueIndex:idx_hourly_rollup_hour_category_user" json:"user_id"`
+	Kg       float64   `json:"kg"`
+}
+
+func dayBucket(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func hourBucket(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), 0, 0, 0, time.UTC)
+}
+
+// applyRollupDelta adjusts the daily/hourly buckets for one activity by
+// deltaKg (positive on create, negative on delete). Must run inside the
+// same transaction as the Activity write so rollups never drift from
+// the rows they summarize.
+func applyRollupDelta(tx *gorm.DB, date time.Time, category string, userID uint, deltaKg float64) error {
+	day := dayBucket(date)
+	hour := hourBucket(date)
+
+	var daily DailyRollup
+	err := tx.Where("date = ? AND category = ? AND user_id = ?", day, category, userID).First(&daily).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		daily = DailyRollup{Date: day, Category: category, UserID: userID, Kg: round2(deltaKg)}
+		if err := tx.Create(&daily).Error; err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if err := tx.Model(&daily).Update("kg", round2(daily.Kg+deltaKg)).Error; err != nil {
+			return err
+		}
+	}
+
+	var hourly HourlyRollup
+	err = tx.Where("hour = ? AND category = ? AND user_id = ?", hour, category, userID).First(&hourly).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		hourly = HourlyRollup{Hour: hour, Category: category, UserID: userID, Kg: round2(deltaKg)}
+		return tx.Create(&hourly).Error
+	case err != nil:
+		return err
+	default:
+		return tx.Model(&hourly).Update("kg", round2(hourly.Kg+deltaKg)).Error
+	}
+}
+
+// backfillRollups re-sums the last 48h of raw activities into the
+// hourly/daily buckets, correcting any drift (e.g. from a crash between
+// the Activity write and the rollup update). It's the rolling job the
+// background worker runs on every tick; it deliberately does not scan
+// the full table, so it is not what fills in buckets older than 48h —
+// fullBackfillRollups does that, once, at startup.
+func backfillRollups(db *gorm.DB) error {
+	since := time.Now().Add(-48 * time.Hour)
+	return resummarizeRollups(db, &since)
+}
+
+// fullBackfillRollups re-sums every activity ever recorded into the
+// rollup tables. It's meant to run once at startup (before the rolling
+// worker takes over) so a fresh rollup feature, or a DB with activities
+// older than 48h, never leaves /api/summary under-reporting versus the
+// raw Activity table it replaced.
+func fullBackfillRollups(db *gorm.DB) error {
+	return resummarizeRollups(db, nil)
+}
+
+// resummarizeRollups re-sums raw activities (all of them, or only those
+// on/after since) into the hourly/daily buckets.
+func resummarizeRollups(db *gorm.DB, since *time.Time) error {
+	query := db.Model(&Activity{})
+	if since != nil {
+		query = query.Where("date >= ?", *since)
+	}
+
+	var items []Activity
+	if err := query.Find(&items).Error; err != nil {
+		return err
+	}
+
+	dailySums := map[string]float64{}
+	hourlySums := map[string]float64{}
+	type bucketKey struct {
+		t      time.Time
+		cat    string
+		userID uint
+	}
+	dailyBuckets := map[string]bucketKey{}
+	hourlyBuckets := map[string]bucketKey{}
+
+	for _, it := range items {
+		uid := strconv.FormatUint(uint64(it.UserID), 10)
+		dk := dayBucket(it.Date).Format(time.RFC3339) + "|" + it.Category + "|" + uid
+		hk := hourBucket(it.Date).Format(time.RFC3339) + "|" + it.Category + "|" + uid
+		dailySums[dk] += it.EmissionKg
+		hourlySums[hk] += it.EmissionKg
+		dailyBuckets[dk] = bucketKey{dayBucket(it.Date), it.Category, it.UserID}
+		hourlyBuckets[hk] = bucketKey{hourBucket(it.Date), it.Category, it.UserID}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for k, sum := range dailySums {
+			b := dailyBuckets[k]
+			var daily DailyRollup
+			err := tx.Where("date = ? AND category = ? AND user_id = ?", b.t, b.cat, b.userID).First(&daily).Error
+			if err == gorm.ErrRecordNotFound {
+				if err := tx.Create(&DailyRollup{Date: b.t, Category: b.cat, UserID: b.userID, Kg: round2(sum)}).Error; err != nil {
+					return err
+				}
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := tx.Model(&daily).Update("kg", round2(sum)).Error; err != nil {
+				return err
+			}
+		}
+		for k, sum := range hourlySums {
+			b := hourlyBuckets[k]
+			var hourly HourlyRollup
+			err := tx.Where("hour = ? AND category = ? AND user_id = ?", b.t, b.cat, b.userID).First(&hourly).Error
+			if err == gorm.ErrRecordNotFound {
+				if err := tx.Create(&HourlyRollup{Hour: b.t, Category: b.cat, UserID: b.userID, Kg: round2(sum)}).Error; err != nil {
+					return err
+				}
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := tx.Model(&hourly).Update("kg", round2(sum)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// startRollupWorker launches the background backfill loop. It runs a
+// full historical backfill once immediately, so a fresh DB (or one with
+// activities older than the 48h rolling window) is never left with
+// stale or missing buckets, then switches to the cheaper rolling 48h
+// re-sum every interval thereafter. Every backfill rewrites rollup
+// buckets, which is exactly the data /api/summary serves out of cache,
+// so each successful run invalidates the cache too — otherwise a drift
+// correction would silently keep serving the stale totals it just fixed.
+func startRollupWorker(db *gorm.DB, cache *summaryCache, interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		if err := fullBackfillRollups(db); err != nil {
+			log.Printf("rollup: initial full backfill failed: %v", err)
+		} else {
+			cache.invalidateAll()
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := backfillRollups(db); err != nil {
+					log.Printf("rollup: backfill failed: %v", err)
+				} else {
+					cache.invalidateAll()
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// --------------------
+// Warm summary cache
+// --------------------
+//
+// Keyed on "from|to|granularity|userID". Read-through on cache miss, invalidated
+// wholesale on any write (activity create/delete), and persisted to disk
+// at shutdown so a restart doesn't cold-start.
+
+type summaryCache struct {
+	mu    sync.RWMutex
+	byKey map[string]SummaryResponse
+	path  string
+}
+
+func newSummaryCache(path string) *summaryCache {
+	return &summaryCache{byKey: map[string]SummaryResponse{}, path: path}
+}
+
+func summaryCacheKey(from, to, granularity string) string {
+	return from + "|" + to + "|" + granularity
+}
+
+func (sc *summaryCache) get(key string) (SummaryResponse, bool) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	v, ok := sc.byKey[key]
+	return v, ok
+}
+
+func (sc *summaryCache) set(key string, v SummaryResponse) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.byKey[key] = v
+}
+
+func (sc *summaryCache) invalidateAll() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.byKey = map[string]SummaryResponse{}
+}
+
+func (sc *summaryCache) loadFromDisk() {
+	b, err := os.ReadFile(sc.path)
+	if err != nil {
+		return // no cache file yet, start cold
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var loaded map[string]SummaryResponse
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		log.Printf("summary cache: ignoring unreadable cache file: %v", err)
+		return
+	}
+	sc.byKey = loaded
+}
+
+func (sc *summaryCache) saveToDisk() {
+	sc.mu.RLock()
+	b, err := json.Marshal(sc.byKey)
+	sc.mu.RUnlock()
+	if err != nil {
+		log.Printf("summary cache: marshal failed: %v", err)
+		return
+	}
+	if err := os.WriteFile(sc.path, b, 0644); err != nil {
+		log.Printf("summary cache: write failed: %v", err)
+	}
+}
+
+// --------------------
+// Rollup-backed summary
+// --------------------
+//
+// querySummaryFromRollups answers /api/summary straight from the
+// DailyRollup/HourlyRollup tables, so cost is O(buckets in range) instead
+// of O(activities in range).
+
+func querySummaryFromRollups(db *gorm.DB, from, to time.Time, granularity string, userID uint) (SummaryResponse, error) {
+	switch granularity {
+	case "hour":
+		return summaryFromHourly(db, from, to, userID)
+	default:
+		return summaryFromDaily(db, from, to, granularity, userID)
+	}
+}
+
+func summaryFromHourly(db *gorm.DB, from, to time.Time, userID uint) (SummaryResponse, error) {
+	var rows []HourlyRollup
+	if err := db.Where("hour BETWEEN ? AND ? AND user_id = ?", from, to, userID).Order("hour asc").Find(&rows).Error; err != nil {
+		return SummaryResponse{}, err
+	}
+
+	byCat := map[string]float64{}
+	byBucket := map[string]float64{}
+	total := 0.0
+	for _, r := range rows {
+		total += r.Kg
+		byCat[r.Category] += r.Kg
+		byBucket[r.Hour.Format(time.RFC3339)] += r.Kg
+	}
+
+	points := []DailyPoint{}
+	for h := hourBucket(from); !h.After(to); h = h.Add(time.Hour) {
+		key := h.Format(time.RFC3339)
+		points = append(points, DailyPoint{Date: key, Kg: round2(byBucket[key])})
+	}
+
+	return SummaryResponse{
+		From:       from.Format("2006-01-02"),
+		To:         to.Format("2006-01-02"),
+		TotalKg:    round2(total),
+		ByCategory: roundMap(byCat),
+		ByDay:      points,
+	}, nil
+}
+
+func summaryFromDaily(db *gorm.DB, from, to time.Time, granularity string, userID uint) (SummaryResponse, error) {
+	var rows []DailyRollup
+	if err := db.Where("date BETWEEN ? AND ? AND user_id = ?", dayBucket(from), dayBucket(to), userID).Order("date asc").Find(&rows).Error; err != nil {
+		return SummaryResponse{}, err
+	}
+
+	byCat := map[string]float64{}
+	byDay := map[string]float64{}
+	total := 0.0
+	for _, r := range rows {
+		total += r.Kg
+		byCat[r.Category] += r.Kg
+		byDay[r.Date.Format("2006-01-02")] += r.Kg
+	}
+
+	var points []DailyPoint
+	switch granularity {
+	case "week":
+		points = bucketByPeriod(from, to, byDay, func(t time.Time) time.Time {
+			weekday := int(t.Weekday())
+			return t.AddDate(0, 0, -weekday) // week starting Sunday
+		})
+	case "month":
+		points = bucketByPeriod(from, to, byDay, func(t time.Time) time.Time {
+			y, m, _ := t.Date()
+			return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		})
+	default: // "day"
+		points = []DailyPoint{}
+		for d := dayBucket(from); !d.After(to); d = d.AddDate(0, 0, 1) {
+			key := d.Format("2006-01-02")
+			points = append(points, DailyPoint{Date: key, Kg: round2(byDay[key])})
+		}
+	}
+
+	return SummaryResponse{
+		From:       from.Format("2006-01-02"),
+		To:         to.Format("2006-01-02"),
+		TotalKg:    round2(total),
+		ByCategory: roundMap(byCat),
+		ByDay:      points,
+	}, nil
+}
+
+// querySummaryFromRollupsForUsers is the group-summary counterpart of
+// querySummaryFromRollups: same buckets, but summed across every member
+// of a group instead of a single user.
+func querySummaryFromRollupsForUsers(db *gorm.DB, from, to time.Time, granularity string, userIDs []uint) (SummaryResponse, error) {
+	switch granularity {
+	case "hour":
+		return summaryFromHourlyUsers(db, from, to, userIDs)
+	default:
+		return summaryFromDailyUsers(db, from, to, granularity, userIDs)
+	}
+}
+
+func summaryFromHourlyUsers(db *gorm.DB, from, to time.Time, userIDs []uint) (SummaryResponse, error) {
+	var rows []HourlyRollup
+	if err := db.Where("hour BETWEEN ? AND ? AND user_id IN ?", from, to, userIDs).Order("hour asc").Find(&rows).Error; err != nil {
+		return SummaryResponse{}, err
+	}
+
+	byCat := map[string]float64{}
+	byBucket := map[string]float64{}
+	total := 0.0
+	for _, r := range rows {
+		total += r.Kg
+		byCat[r.Category] += r.Kg
+		byBucket[r.Hour.Format(time.RFC3339)] += r.Kg
+	}
+
+	points := []DailyPoint{}
+	for h := hourBucket(from); !h.After(to); h = h.Add(time.Hour) {
+		key := h.Format(time.RFC3339)
+		points = append(points, DailyPoint{Date: key, Kg: round2(byBucket[key])})
+	}
+
+	return SummaryResponse{
+		From:       from.Format("2006-01-02"),
+		To:         to.Format("2006-01-02"),
+		TotalKg:    round2(total),
+		ByCategory: roundMap(byCat),
+		ByDay:      points,
+	}, nil
+}
+
+func summaryFromDailyUsers(db *gorm.DB, from, to time.Time, granularity string, userIDs []uint) (SummaryResponse, error) {
+	var rows []DailyRollup
+	if err := db.Where("date BETWEEN ? AND ? AND user_id IN ?", dayBucket(from), dayBucket(to), userIDs).Order("date asc").Find(&rows).Error; err != nil {
+		return SummaryResponse{}, err
+	}
+
+	byCat := map[string]float64{}
+	byDay := map[string]float64{}
+	total := 0.0
+	for _, r := range rows {
+		total += r.Kg
+		byCat[r.Category] += r.Kg
+		byDay[r.Date.Format("2006-01-02")] += r.Kg
+	}
+
+	var points []DailyPoint
+	switch granularity {
+	case "week":
+		points = bucketByPeriod(from, to, byDay, func(t time.Time) time.Time {
+			weekday := int(t.Weekday())
+			return t.AddDate(0, 0, -weekday)
+		})
+	case "month":
+		points = bucketByPeriod(from, to, byDay, func(t time.Time) time.Time {
+			y, m, _ := t.Date()
+			return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		})
+	default: // "day"
+		points = []DailyPoint{}
+		for d := dayBucket(from); !d.After(to); d = d.AddDate(0, 0, 1) {
+			key := d.Format("2006-01-02")
+			points = append(points, DailyPoint{Date: key, Kg: round2(byDay[key])})
+		}
+	}
+
+	return SummaryResponse{
+		From:       from.Format("2006-01-02"),
+		To:         to.Format("2006-01-02"),
+		TotalKg:    round2(total),
+		ByCategory: roundMap(byCat),
+		ByDay:      points,
+	}, nil
+}
+
+// bucketByPeriod folds daily sums (keyed "YYYY-MM-DD") into coarser
+// periods using bucketStart to find each day's period start.
+func bucketByPeriod(from, to time.Time, byDay map[string]float64, bucketStart func(time.Time) time.Time) []DailyPoint {
+	sums := map[string]float64{}
+	var order []string
+	seen := map[string]bool{}
+	for d := dayBucket(from); !d.After(to); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		bucket := bucketStart(d).Format("2006-01-02")
+		sums[bucket] += byDay[key]
+		if !seen[bucket] {
+			seen[bucket] = true
+			order = append(order, bucket)
+		}
+	}
+	points := make([]DailyPoint, 0, len(order))
+	for _, b := range order {
+		points = append(points, DailyPoint{Date: b, Kg: round2(sums[b])})
+	}
+	return points
+}
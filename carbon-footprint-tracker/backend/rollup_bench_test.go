@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// summaryFromRawActivities is the O(activities-in-range) approach
+// /api/summary used before the rollup tables: scan every raw Activity
+// row in the window and sum in-process. It's kept here, test-only, as
+// the baseline the rollup-backed path is benchmarked against.
+func summaryFromRawActivities(db *gorm.DB, from, to time.Time, userID uint) (SummaryResponse, error) {
+	var items []Activity
+	if err := db.Where("user_id = ? AND date BETWEEN ? AND ?", userID, from, to).Find(&items).Error; err != nil {
+		return SummaryResponse{}, err
+	}
+
+	byCat := map[string]float64{}
+	byDay := map[string]float64{}
+	total := 0.0
+	for _, it := range items {
+		total += it.EmissionKg
+		byCat[it.Category] += it.EmissionKg
+		byDay[it.Date.Format("2006-01-02")] += it.EmissionKg
+	}
+
+	points := []DailyPoint{}
+	for d := dayBucket(from); !d.After(to); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		points = append(points, DailyPoint{Date: key, Kg: round2(byDay[key])})
+	}
+
+	return SummaryResponse{
+		From:       from.Format("2006-01-02"),
+		To:         to.Format("2006-01-02"),
+		TotalKg:    round2(total),
+		ByCategory: roundMap(byCat),
+		ByDay:      points,
+	}, nil
+}
+
+// seedRollupBenchDB populates `days` worth of activities at
+// perDayActivities/day for one user, plus the rollup buckets those
+// activities feed, so both query paths answer from the same data.
+func seedRollupBenchDB(b *testing.B, days, perDayActivities int) (*gorm.DB, time.Time, time.Time) {
+	b.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}, &Activity{}, &DailyRollup{}, &HourlyRollup{}); err != nil {
+		b.Fatal(err)
+	}
+
+	const userID = 1
+	categories := []string{"transport", "energy", "food", "shopping"}
+	start := time.Now().AddDate(0, 0, -days)
+
+	for d := 0; d < days; d++ {
+		date := start.AddDate(0, 0, d)
+		for i := 0; i < perDayActivities; i++ {
+			cat := categories[i%len(categories)]
+			item := Activity{UserID: userID, Category: cat, Type: "bench", EmissionKg: 1.5, Date: date}
+			if err := db.Create(&item).Error; err != nil {
+				b.Fatal(err)
+			}
+			if err := applyRollupDelta(db, item.Date, item.Category, item.UserID, item.EmissionKg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	return db, start, start.AddDate(0, 0, days)
+}
+
+// BenchmarkQuerySummaryFromRollups demonstrates the rollup-backed path's
+// cost is O(buckets in range): it should stay roughly flat as
+// perDayActivities grows, unlike BenchmarkSummaryFromRawActivities.
+func BenchmarkQuerySummaryFromRollups(b *testing.B) {
+	for _, perDay := range []int{10, 100, 1000} {
+		db, from, to := seedRollupBenchDB(b, 30, perDay)
+		b.Run(benchName(perDay), func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := querySummaryFromRollups(db, from, to, "day", 1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSummaryFromRawActivities is the pre-rollup baseline: its cost
+// grows with the number of activities in range, not the range itself.
+func BenchmarkSummaryFromRawActivities(b *testing.B) {
+	for _, perDay := range []int{10, 100, 1000} {
+		db, from, to := seedRollupBenchDB(b, 30, perDay)
+		b.Run(benchName(perDay), func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := summaryFromRawActivities(db, from, to, 1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func benchName(perDayActivities int) string {
+	return "perDayActivities=" + strconv.Itoa(perDayActivities)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// reportSchedulerInterval controls how often we check ScheduledReport
+// rows against the current minute. A minute granularity matches what
+// cron expressions can express anyway.
+const reportSchedulerInterval = 1 * time.Minute
+
+// startReportScheduler polls ScheduledReport rows every tick and emails
+// any whose Cron matches the current minute and haven't already run this
+// minute.
+func startReportScheduler(app *App, mailer Mailer, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(reportSchedulerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				runDueReports(app, mailer)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func runDueReports(app *App, mailer Mailer) {
+	now := time.Now()
+
+	var reports []ScheduledReport
+	if err := app.DB.Find(&reports).Error; err != nil {
+		log.Printf("report scheduler: could not list scheduled reports: %v", err)
+		return
+	}
+
+	for _, r := range reports {
+		if r.LastRunAt != nil && sameMinute(*r.LastRunAt, now) {
+			continue
+		}
+		if !matchesCron(r.Cron, now) {
+			continue
+		}
+		if err := runScheduledReport(app, mailer, r, now); err != nil {
+			log.Printf("report scheduler: report %d failed: %v", r.ID, err)
+		}
+	}
+}
+
+func runScheduledReport(app *App, mailer Mailer, r ScheduledReport, runAt time.Time) error {
+	body, contentType, err := app.renderReport(r)
+	if err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+
+	filename := "report." + r.Format
+	subject := fmt.Sprintf("Carbon footprint report (%s)", r.Preset)
+	if err := mailer.Send(r.Email, subject, "Your scheduled carbon footprint report is attached.", body, filename, contentType); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+
+	return app.DB.Model(&ScheduledReport{}).Where("id = ?", r.ID).Update("last_run_at", &runAt).Error
+}
+
+func sameMinute(a, b time.Time) bool {
+	return a.Truncate(time.Minute).Equal(b.Truncate(time.Minute))
+}